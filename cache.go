@@ -0,0 +1,308 @@
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// newCache returns a new cache.
+func newCache() *cache {
+	c := &cache{
+		m:    make(map[reflect.Type]*structInfo),
+		conv: make(map[reflect.Type]ErrorConverter),
+		enc:  make(map[reflect.Type]EncoderFunc),
+		tag:  "schema",
+	}
+	for k, v := range converters {
+		c.conv[k] = v
+	}
+	for k, v := range encoders {
+		c.enc[k] = v
+	}
+	return c
+}
+
+// cache caches meta-data about structs, and holds the converters and
+// encoders used to translate individual field values.
+type cache struct {
+	l    sync.Mutex
+	m    map[reflect.Type]*structInfo
+	conv map[reflect.Type]ErrorConverter
+	enc  map[reflect.Type]EncoderFunc
+	tag  string
+}
+
+// get returns the structInfo for t, computing and caching it the first
+// time t is seen. The struct is re-validated (and not cached) if that
+// computation fails, so a malformed tag is reported again on the next
+// call rather than wedging the cache.
+func (c *cache) get(t reflect.Type) (*structInfo, error) {
+	c.l.Lock()
+	info, ok := c.m[t]
+	c.l.Unlock()
+	if ok {
+		return info, nil
+	}
+	info, err := c.create(t)
+	if err != nil {
+		return nil, err
+	}
+	c.l.Lock()
+	c.m[t] = info
+	c.l.Unlock()
+	return info, nil
+}
+
+// create builds a structInfo by walking the fields of t.
+func (c *cache) create(t reflect.Type) (*structInfo, error) {
+	info := &structInfo{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		alias, opts := parseTag(field.Tag.Get(c.tag))
+		if alias == "-" {
+			// Ignore this field.
+			continue
+		}
+		if alias == "" {
+			alias = field.Name
+		}
+		fi := &fieldInfo{typ: field.Type, idx: i, alias: alias}
+		for _, opt := range opts {
+			switch {
+			case strings.HasPrefix(opt, "default:"):
+				defaults, err := c.parseDefault(field.Type, strings.TrimPrefix(opt, "default:"))
+				if err != nil {
+					return nil, fmt.Errorf("schema: field %q: %v", field.Name, err)
+				}
+				fi.def = defaults
+			case opt == "required":
+				fi.required = true
+			}
+		}
+		nested, hasNested, err := c.nestedInfo(field.Type)
+		if err != nil {
+			return nil, err
+		}
+		if fi.required && isMultiSegmentField(c, field.Type) {
+			return nil, fmt.Errorf("schema: field %q: required is not supported on nested struct, map, or slice-of-struct fields, only on leaf fields", field.Name)
+		}
+		if len(fi.def) > 0 || (hasNested && nested.hasDefaults) {
+			info.hasDefaults = true
+		}
+		if fi.required || (hasNested && nested.hasRequired) {
+			info.hasRequired = true
+		}
+		info.fields = append(info.fields, fi)
+	}
+	return info, nil
+}
+
+// nestedInfo returns the structInfo for ft if it is a struct (or a
+// pointer to one) that isn't handled by a registered converter, so its
+// own fields can be inspected for defaults.
+func (c *cache) nestedInfo(ft reflect.Type) (*structInfo, bool, error) {
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	if ft.Kind() != reflect.Struct || c.converter(ft) != nil {
+		return nil, false, nil
+	}
+	info, err := c.get(ft)
+	if err != nil {
+		return nil, false, err
+	}
+	return info, true, nil
+}
+
+// parseDefault converts the pipe-separated default values from a
+// "default:..." tag option, using the converter registered for ft (or
+// its slice element type). Validating eagerly here means a malformed
+// default is reported when the struct is first cached, instead of on
+// the first Decode that happens to need it.
+func (c *cache) parseDefault(ft reflect.Type, raw string) ([]reflect.Value, error) {
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	convT := ft
+	if ft.Kind() == reflect.Slice {
+		convT = ft.Elem()
+		if convT.Kind() == reflect.Ptr {
+			convT = convT.Elem()
+		}
+	}
+	conv := c.converter(convT)
+	if conv == nil {
+		return nil, fmt.Errorf("no converter registered for %v", convT)
+	}
+	rawValues := strings.Split(raw, "|")
+	defaults := make([]reflect.Value, len(rawValues))
+	for i, rv := range rawValues {
+		value, err := conv(rv)
+		if err != nil || !value.IsValid() {
+			return nil, fmt.Errorf("invalid default %q", rv)
+		}
+		defaults[i] = value
+	}
+	return defaults, nil
+}
+
+// structInfo stores the fields of a struct, indexed by their effective
+// name.
+type structInfo struct {
+	fields      []*fieldInfo
+	hasDefaults bool // true if this struct, or one nested in it, has a default tag
+	hasRequired bool // true if this struct, or one nested in it, has a required tag
+}
+
+// get returns the field registered under alias, or nil if there is none.
+func (i *structInfo) get(alias string) *fieldInfo {
+	for _, f := range i.fields {
+		if f.alias == alias {
+			return f
+		}
+	}
+	return nil
+}
+
+// fieldInfo stores meta-data about a single struct field.
+type fieldInfo struct {
+	typ      reflect.Type
+	idx      int             // index of the field in the parent struct
+	alias    string          // effective name, from the tag or the field name
+	def      []reflect.Value // default value(s), from a "default:" tag option
+	required bool            // true if the field carries a "required" tag option
+}
+
+// parseTag splits a struct tag in "alias,opt1,opt2" format into the
+// alias and its options. The special alias "-" means the field should
+// be ignored.
+func parseTag(tag string) (alias string, opts []string) {
+	if tag == "" {
+		return "", nil
+	}
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:]
+}
+
+// pathPart keeps the information needed to set a field once the
+// corresponding values are available: the sequence of struct field
+// indexes to reach it and, for slice-of-struct elements, which index
+// this part refers to. A part that addresses a map field instead carries
+// its string key in mapKey, and is always the last part of a path, since
+// map values are set directly rather than walked further.
+type pathPart struct {
+	path   []int  // indexes to walk, in order, to reach the field
+	index  int    // slice index for this segment; -1 when not a slice
+	isMap  bool   // true if this part addresses a map field
+	mapKey string // map key for this segment, valid only when isMap
+}
+
+// parsePath parses keys, the segments of a path split by the decoder
+// according to its configured PathStyle, verifying that it is a valid
+// path to a field in t. original is the unsplit path, used only to
+// format error messages. For example, keys ["Foo", "Bar"] is the Bar
+// field of the struct in the Foo field; ["Foo", "0", "Bar"] is the Bar
+// field of the first element of the Foo slice; ["Foo", "key"] is the
+// "key" entry of the Foo map.
+func (c *cache) parsePath(keys []string, original string, t reflect.Type) ([]pathPart, error) {
+	if len(keys) == 0 {
+		// A path that splits into no segments at all, e.g. "" or ".",
+		// addresses nothing; reject it instead of returning an empty
+		// parts slice that decode would index into and panic on.
+		return nil, fmt.Errorf("schema: invalid path %q", original)
+	}
+	parts := make([]pathPart, 0)
+	path := make([]int, 0)
+	for i := 0; i < len(keys); i++ {
+		if t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("schema: invalid path %q", original)
+		}
+		info, err := c.get(t)
+		if err != nil {
+			return nil, err
+		}
+		field := info.get(keys[i])
+		if field == nil {
+			return nil, fmt.Errorf("schema: invalid path %q", original)
+		}
+		path = append(path, field.idx)
+		ft := field.typ
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Map:
+			// The next key is the map key; nothing follows it.
+			i++
+			if i >= len(keys) {
+				return nil, fmt.Errorf("schema: path %q is missing a key for map field %q", original, keys[i-1])
+			}
+			if i+1 < len(keys) {
+				return nil, fmt.Errorf("schema: invalid path %q", original)
+			}
+			parts = append(parts, pathPart{path: path, index: -1, isMap: true, mapKey: keys[i]})
+		case ft.Kind() == reflect.Slice && sliceOfStructs(c, ft.Elem()):
+			// Slice of structs: the next key must be the element index.
+			i++
+			if i >= len(keys) {
+				return nil, fmt.Errorf("schema: path %q is missing an index for field %q", original, keys[i-1])
+			}
+			index, err := strconv.Atoi(keys[i])
+			if err != nil {
+				return nil, fmt.Errorf("schema: invalid path %q", original)
+			}
+			parts = append(parts, pathPart{path: path, index: index})
+			path = make([]int, 0)
+			t = ft.Elem()
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+		case ft.Kind() == reflect.Struct && c.converter(ft) == nil:
+			t = ft
+		default:
+			parts = append(parts, pathPart{path: path, index: -1})
+		}
+	}
+	return parts, nil
+}
+
+// sliceOfStructs reports whether elemT (the element type of a slice
+// field) should be addressed with an index in the path, i.e. it is a
+// struct (or pointer to one) with no registered converter of its own.
+// A struct type that does have one, such as time.Time, is decoded like
+// any other scalar: as multiple values under the slice field's own key.
+func sliceOfStructs(c *cache, elemT reflect.Type) bool {
+	if elemT.Kind() == reflect.Ptr {
+		elemT = elemT.Elem()
+	}
+	return elemT.Kind() == reflect.Struct && c.converter(elemT) == nil
+}
+
+// isMultiSegmentField reports whether a field of type ft is addressed by
+// more than one path segment: a nested struct with no converter of its
+// own, a map, or a slice of structs. The "required" tag isn't supported
+// on any of these, since checkRequired looks a field up by its own
+// top-level key, which never appears verbatim in src for one of these -
+// "addrs.0.City" or "filters.color", never "addrs" or "filters" alone.
+func isMultiSegmentField(c *cache, ft reflect.Type) bool {
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	switch {
+	case ft.Kind() == reflect.Struct:
+		return c.converter(ft) == nil
+	case ft.Kind() == reflect.Map:
+		return true
+	case ft.Kind() == reflect.Slice:
+		return sliceOfStructs(c, ft.Elem())
+	default:
+		return false
+	}
+}
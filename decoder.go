@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 // NewDecoder returns a new Decoder.
@@ -17,10 +18,43 @@ func NewDecoder() *Decoder {
 
 // Decoder decodes values from a map[string][]string to a struct.
 type Decoder struct {
-	cache *cache
+	cache         *cache
+	ignoreUnknown bool
+	requireTagged bool
+	pathStyle     PathStyle
+}
+
+// SetAliasTag sets the tag used to locate custom field aliases.
+// The default tag is "schema".
+func (d *Decoder) SetAliasTag(tag string) {
+	d.cache.tag = tag
+}
+
+// IgnoreUnknownKeys controls whether Decode treats a key in src that
+// does not match any path in the destination struct as an error. The
+// default is false, so unknown keys are reported in the returned
+// MultiError; pass true to ignore them, e.g. when decoding a form that
+// contains fields unrelated to the target struct.
+func (d *Decoder) IgnoreUnknownKeys(ignore bool) {
+	d.ignoreUnknown = ignore
+}
+
+// SetRequired controls whether a field tagged with the "required" option,
+// e.g. `schema:"name,required"`, must be present and non-empty in src.
+// The default is false, so the required tag has no effect until this is
+// called with true; a violation is reported as a RequiredError. The tag
+// is only valid on leaf fields, not on nested structs, and is a no-op on
+// a field that also carries a "default:" option, since that default
+// always satisfies it.
+func (d *Decoder) SetRequired(required bool) {
+	d.requireTagged = required
 }
 
 // RegisterConverter registers a converter function for a custom type.
+//
+// This is only needed for types that implement neither
+// encoding.TextUnmarshaler nor encoding.BinaryUnmarshaler, which are used
+// automatically as a fallback when no converter is registered.
 func (d *Decoder) RegisterConverter(value interface{}, converterFunc Converter) {
 	d.cache.conv[reflect.TypeOf(value)] = wrapConverter(converterFunc)
 }
@@ -35,7 +69,14 @@ func (d *Decoder) RegisterErrorConverter(value interface{}, converterFunc ErrorC
 // The first parameter must be a pointer to a struct.
 //
 // The second parameter is a map, typically url.Values from an HTTP request.
-// Keys are "paths" in dotted notation to the struct fields and nested structs.
+// Keys are "paths" to the struct fields, nested structs and map entries,
+// in the notation set by SetPathStyle: dotted ("Foo.0.Bar", "Filters.color")
+// by default, or bracketed ("Foo[0].Bar", "Filters[color]") when configured.
+//
+// A field tagged with a "default:" option, e.g. `schema:"name,default:hello"`,
+// is set to that value (pipe-separated for slice fields, as in
+// "default:hello|world") when src has no value, or only an empty one, for
+// its key.
 //
 // See the package documentation for a full explanation of the mechanics.
 func (d *Decoder) Decode(dst interface{}, src map[string][]string) error {
@@ -46,15 +87,31 @@ func (d *Decoder) Decode(dst interface{}, src map[string][]string) error {
 	v = v.Elem()
 	t := v.Type()
 	errors := MultiError{}
+	// normSrc is src re-keyed by the canonical dotted form of each path,
+	// so that applyDefaults and checkRequired can look keys up regardless
+	// of the decoder's configured PathStyle.
+	normSrc := make(map[string][]string, len(src))
 	for path, values := range src {
-		if parts, err := d.cache.parsePath(path, t); err == nil {
+		keys, err := d.splitPath(path)
+		if err != nil {
+			if !d.ignoreUnknown {
+				errors[path] = err
+			}
+			continue
+		}
+		normSrc[strings.Join(keys, ".")] = values
+		if parts, err := d.cache.parsePath(keys, path, t); err == nil {
 			if err = d.decode(v, path, parts, values); err != nil {
 				errors[path] = err
 			}
-		} else {
+		} else if !d.ignoreUnknown {
 			errors[path] = fmt.Errorf("schema: invalid path %q", path)
 		}
 	}
+	d.applyDefaults(v, "", normSrc, errors)
+	if d.requireTagged {
+		d.checkRequired(t, "", normSrc, errors)
+	}
 	if len(errors) > 0 {
 		return errors
 	}
@@ -99,9 +156,14 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart,
 		return d.decode(v.Index(idx), path, parts[1:], values)
 	}
 
+	// Map field, keyed by parts[0].mapKey.
+	if parts[0].isMap {
+		return d.decodeMapEntry(v, path, parts[0].mapKey, values)
+	}
+
 	// Simple case.
-	if d.cache.conv[t] != nil || t.Kind() != reflect.Slice {
-		if conv := d.cache.conv[t]; conv != nil {
+	if d.cache.converter(t) != nil || t.Kind() != reflect.Slice {
+		if conv := d.cache.converter(t); conv != nil {
 			if value, err := conv(values[0]); value.IsValid() {
 				v.Set(value)
 			} else if err != nil {
@@ -117,7 +179,7 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart,
 		if isPtrElem {
 			elemT = elemT.Elem()
 		}
-		conv := d.cache.conv[elemT]
+		conv := d.cache.converter(elemT)
 		if conv == nil {
 			return fmt.Errorf("schema: converter not found for %v", elemT)
 		}
@@ -144,8 +206,190 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart,
 	return nil
 }
 
+// decodeMapEntry sets the entry for key in the map v, which must be of
+// kind map, converting key with the converter registered for the map's
+// key type and values with the converter for its value type (or, for a
+// map[K][]V field, the converter for V, applied to every value).
+func (d *Decoder) decodeMapEntry(v reflect.Value, path, key string, values []string) error {
+	t := v.Type()
+	keyConv := d.cache.converter(t.Key())
+	if keyConv == nil {
+		return fmt.Errorf("schema: converter not found for map key type %v", t.Key())
+	}
+	keyValue, err := keyConv(key)
+	if err != nil || !keyValue.IsValid() {
+		return ConversionError{path, -1, err}
+	}
+
+	elemT := t.Elem()
+	if elemT.Kind() != reflect.Slice {
+		conv := d.cache.converter(elemT)
+		if conv == nil {
+			return fmt.Errorf("schema: converter not found for %v", elemT)
+		}
+		elemValue, err := conv(values[0])
+		if err != nil || !elemValue.IsValid() {
+			return ConversionError{path, -1, err}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		v.SetMapIndex(keyValue, elemValue)
+		return nil
+	}
+
+	items := make([]reflect.Value, 0, len(values))
+	conv := d.cache.converter(elemT.Elem())
+	if conv == nil {
+		return fmt.Errorf("schema: converter not found for %v", elemT.Elem())
+	}
+	for i, value := range values {
+		if value == "" {
+			// We are just ignoring empty values for now.
+			continue
+		}
+		item, err := conv(value)
+		if err != nil || !item.IsValid() {
+			return ConversionError{path, i, err}
+		}
+		items = append(items, item)
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	v.SetMapIndex(keyValue, reflect.Append(reflect.MakeSlice(elemT, 0, 0), items...))
+	return nil
+}
+
+// applyDefaults walks v, setting any field tagged with a "default:"
+// option that src left unset, i.e. whose key is absent from src or
+// whose value is empty. It recurses into nested structs, skipping the
+// walk entirely (and any allocation of nil pointers) for structs that
+// have no default anywhere inside them.
+func (d *Decoder) applyDefaults(v reflect.Value, path string, src map[string][]string, errs MultiError) {
+	info, err := d.cache.get(v.Type())
+	if err != nil {
+		errs[path] = err
+		return
+	}
+	if !info.hasDefaults {
+		return
+	}
+	for _, field := range info.fields {
+		name := field.alias
+		if path != "" {
+			name = path + "." + name
+		}
+		fv := v.Field(field.idx)
+		ft := fv.Type()
+		isPtr := ft.Kind() == reflect.Ptr
+		elemT := ft
+		if isPtr {
+			elemT = ft.Elem()
+		}
+
+		if elemT.Kind() == reflect.Struct && d.cache.converter(elemT) == nil {
+			nested, err := d.cache.get(elemT)
+			if err != nil {
+				errs[name] = err
+				continue
+			}
+			if !nested.hasDefaults {
+				continue
+			}
+			ev := fv
+			if isPtr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(elemT))
+				}
+				ev = fv.Elem()
+			}
+			d.applyDefaults(ev, name, src, errs)
+			continue
+		}
+
+		if len(field.def) == 0 {
+			continue
+		}
+		if values, ok := src[name]; ok && !(len(values) == 1 && values[0] == "") {
+			continue
+		}
+		value := field.def[0]
+		if elemT.Kind() == reflect.Slice {
+			sliceElemT := elemT.Elem()
+			isPtrElem := sliceElemT.Kind() == reflect.Ptr
+			value = reflect.MakeSlice(elemT, len(field.def), len(field.def))
+			for i, def := range field.def {
+				item := def
+				if isPtrElem {
+					ptr := reflect.New(sliceElemT.Elem())
+					ptr.Elem().Set(def)
+					item = ptr
+				}
+				value.Index(i).Set(item)
+			}
+		}
+		if isPtr {
+			if fv.IsNil() {
+				fv.Set(reflect.New(elemT))
+			}
+			fv.Elem().Set(value)
+		} else {
+			fv.Set(value)
+		}
+	}
+}
+
+// checkRequired reports a RequiredError for every field of t tagged
+// ",required" whose path is absent from src, or present only as an
+// empty string. A field that also carries a "default:" option is never
+// reported, since applyDefaults has already given it a value by the
+// time Decode returns. It walks the type rather than a value, since
+// there is nothing to set here.
+func (d *Decoder) checkRequired(t reflect.Type, path string, src map[string][]string, errs MultiError) {
+	info, err := d.cache.get(t)
+	if err != nil {
+		errs[path] = err
+		return
+	}
+	if !info.hasRequired {
+		return
+	}
+	for _, field := range info.fields {
+		name := field.alias
+		if path != "" {
+			name = path + "." + name
+		}
+		ft := field.typ
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && d.cache.converter(ft) == nil {
+			d.checkRequired(ft, name, src, errs)
+			continue
+		}
+		if !field.required || len(field.def) > 0 {
+			continue
+		}
+		if values, ok := src[name]; ok && !(len(values) == 1 && values[0] == "") {
+			continue
+		}
+		errs[name] = RequiredError{Key: name}
+	}
+}
+
 // Errors ---------------------------------------------------------------------
 
+// RequiredError is returned when a field tagged ",required" is missing
+// from the source map, or present only as an empty string.
+type RequiredError struct {
+	Key string // key from the source map.
+}
+
+func (e RequiredError) Error() string {
+	return fmt.Sprintf("schema: required field %q is empty", e.Key)
+}
+
 // ConversionError stores information about a failed conversion.
 type ConversionError struct {
 	Key   string // key from the source map.
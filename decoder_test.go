@@ -0,0 +1,316 @@
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import (
+	"strconv"
+	"testing"
+)
+
+// hexID is a custom type with no registered converter, used to exercise
+// the TextUnmarshaler/TextMarshaler fallback.
+type hexID uint32
+
+func (h *hexID) UnmarshalText(text []byte) error {
+	v, err := strconv.ParseUint(string(text), 16, 32)
+	if err != nil {
+		return err
+	}
+	*h = hexID(v)
+	return nil
+}
+
+func (h hexID) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(h), 16)), nil
+}
+
+func TestDecodeBasic(t *testing.T) {
+	type simple struct {
+		Foo string
+		Bar int
+	}
+	var dst simple
+	src := map[string][]string{"Foo": {"hello"}, "Bar": {"42"}}
+	if err := NewDecoder().Decode(&dst, src); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if dst.Foo != "hello" || dst.Bar != 42 {
+		t.Errorf("got %+v", dst)
+	}
+}
+
+func TestDecodeDefault(t *testing.T) {
+	type withDefaults struct {
+		Name string   `schema:"name,default:anonymous"`
+		Tags []string `schema:"tags,default:a|b"`
+	}
+	var dst withDefaults
+	if err := NewDecoder().Decode(&dst, map[string][]string{}); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if dst.Name != "anonymous" {
+		t.Errorf("Name = %q, want %q", dst.Name, "anonymous")
+	}
+	if len(dst.Tags) != 2 || dst.Tags[0] != "a" || dst.Tags[1] != "b" {
+		t.Errorf("Tags = %v, want [a b]", dst.Tags)
+	}
+
+	// An empty value for the key should also fall back to the default.
+	dst = withDefaults{}
+	src := map[string][]string{"name": {""}}
+	if err := NewDecoder().Decode(&dst, src); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if dst.Name != "anonymous" {
+		t.Errorf("Name = %q, want %q", dst.Name, "anonymous")
+	}
+
+	// A non-empty value overrides the default.
+	dst = withDefaults{}
+	src = map[string][]string{"name": {"bob"}}
+	if err := NewDecoder().Decode(&dst, src); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if dst.Name != "bob" {
+		t.Errorf("Name = %q, want %q", dst.Name, "bob")
+	}
+}
+
+func TestDecodeDefaultSliceOfPointers(t *testing.T) {
+	type withPtrDefaults struct {
+		Nums []*int `schema:"nums,default:1|2"`
+	}
+	var dst withPtrDefaults
+	if err := NewDecoder().Decode(&dst, map[string][]string{}); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(dst.Nums) != 2 || dst.Nums[0] == nil || dst.Nums[1] == nil || *dst.Nums[0] != 1 || *dst.Nums[1] != 2 {
+		t.Errorf("Nums = %v, want pointers to [1 2]", dst.Nums)
+	}
+}
+
+func TestDecodeRequired(t *testing.T) {
+	type withRequired struct {
+		Name string `schema:"name,required"`
+	}
+	d := NewDecoder()
+	d.SetRequired(true)
+	var dst withRequired
+	err := d.Decode(&dst, map[string][]string{})
+	if err == nil {
+		t.Fatal("Decode: got nil error, want a RequiredError")
+	}
+	merr, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("Decode error is %T, want MultiError", err)
+	}
+	if _, ok := merr["name"].(RequiredError); !ok {
+		t.Errorf("merr[%q] = %v (%T), want a RequiredError", "name", merr["name"], merr["name"])
+	}
+
+	// Present and non-empty: no error.
+	dst = withRequired{}
+	if err := d.Decode(&dst, map[string][]string{"name": {"bob"}}); err != nil {
+		t.Errorf("Decode: %v", err)
+	}
+}
+
+func TestDecodeRequiredWithDefault(t *testing.T) {
+	// A field tagged both "default:" and "required" should never be
+	// reported as missing: the default always satisfies it.
+	type withBoth struct {
+		Count int `schema:"count,default:5,required"`
+	}
+	d := NewDecoder()
+	d.SetRequired(true)
+	var dst withBoth
+	if err := d.Decode(&dst, map[string][]string{}); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if dst.Count != 5 {
+		t.Errorf("Count = %d, want 5", dst.Count)
+	}
+}
+
+func TestDecodeRequiredOnNestedStructRejected(t *testing.T) {
+	// "required" on a struct-typed field isn't supported; the cache
+	// should report it eagerly instead of silently ignoring it.
+	type addr struct {
+		City string
+	}
+	type withNested struct {
+		Address addr `schema:"address,required"`
+	}
+	var dst withNested
+	err := NewDecoder().Decode(&dst, map[string][]string{})
+	if err == nil {
+		t.Fatal("Decode: got nil error, want a cache error about nested required fields")
+	}
+}
+
+func TestDecodeRequiredOnMapOrSliceOfStructRejected(t *testing.T) {
+	// "required" isn't supported on a map field either: checkRequired
+	// would look it up by its bare key, which never appears verbatim in
+	// src since map entries are addressed as "filters.color".
+	type withMap struct {
+		Filters map[string]string `schema:"filters,required"`
+	}
+	var dstMap withMap
+	if err := NewDecoder().Decode(&dstMap, map[string][]string{"filters.color": {"red"}}); err == nil {
+		t.Fatal("Decode: got nil error, want a cache error about required map fields")
+	}
+
+	// Nor on a slice of structs, for the same reason ("addrs.0.City").
+	type addr struct {
+		City string
+	}
+	type withSlice struct {
+		Addrs []addr `schema:"addrs,required"`
+	}
+	var dstSlice withSlice
+	if err := NewDecoder().Decode(&dstSlice, map[string][]string{"addrs.0.City": {"NYC"}}); err == nil {
+		t.Fatal("Decode: got nil error, want a cache error about required slice-of-struct fields")
+	}
+}
+
+func TestDecodeIgnoreUnknownKeys(t *testing.T) {
+	type simple struct {
+		Foo string
+	}
+	src := map[string][]string{"Foo": {"hi"}, "Bar": {"unknown"}}
+
+	var dst simple
+	if err := NewDecoder().Decode(&dst, src); err == nil {
+		t.Fatal("Decode: got nil error for an unknown key")
+	}
+
+	d := NewDecoder()
+	d.IgnoreUnknownKeys(true)
+	dst = simple{}
+	if err := d.Decode(&dst, src); err != nil {
+		t.Fatalf("Decode with IgnoreUnknownKeys(true): %v", err)
+	}
+	if dst.Foo != "hi" {
+		t.Errorf("Foo = %q, want %q", dst.Foo, "hi")
+	}
+}
+
+func TestDecodePathStyle(t *testing.T) {
+	type inner struct {
+		Bar string
+	}
+	type outer struct {
+		Foo inner
+	}
+
+	dotted := map[string][]string{"Foo.Bar": {"hi"}}
+	bracketed := map[string][]string{"Foo[Bar]": {"hi"}}
+
+	// PathDotted (the default) accepts dotted paths.
+	var dst outer
+	if err := NewDecoder().Decode(&dst, dotted); err != nil {
+		t.Fatalf("PathDotted on dotted path: %v", err)
+	}
+	if dst.Foo.Bar != "hi" {
+		t.Errorf("Foo.Bar = %q, want %q", dst.Foo.Bar, "hi")
+	}
+
+	// PathBracket accepts bracketed paths...
+	d := NewDecoder()
+	d.SetPathStyle(PathBracket)
+	dst = outer{}
+	if err := d.Decode(&dst, bracketed); err != nil {
+		t.Fatalf("PathBracket on bracketed path: %v", err)
+	}
+	if dst.Foo.Bar != "hi" {
+		t.Errorf("Foo.Bar = %q, want %q", dst.Foo.Bar, "hi")
+	}
+
+	// ...but rejects a bare dotted separator.
+	dst = outer{}
+	if err := d.Decode(&dst, dotted); err == nil {
+		t.Error("PathBracket on dotted path: got nil error, want one rejecting the notation")
+	}
+
+	// PathAny accepts both notations with the same decoder.
+	d = NewDecoder()
+	d.SetPathStyle(PathAny)
+	dst = outer{}
+	mixed := map[string][]string{"Foo.Bar": {"a"}}
+	if err := d.Decode(&dst, mixed); err != nil {
+		t.Fatalf("PathAny on dotted path: %v", err)
+	}
+	dst = outer{}
+	if err := d.Decode(&dst, bracketed); err != nil {
+		t.Fatalf("PathAny on bracketed path: %v", err)
+	}
+}
+
+func TestDecodeEmptyPath(t *testing.T) {
+	// A key that splits into zero segments must be rejected, not panic.
+	type simple struct {
+		Foo string
+	}
+	for _, key := range []string{"", "."} {
+		d := NewDecoder()
+		d.SetPathStyle(PathAny)
+		var dst simple
+		if err := d.Decode(&dst, map[string][]string{key: {"x"}}); err == nil {
+			t.Errorf("Decode with key %q: got nil error, want one rejecting the empty path", key)
+		}
+	}
+}
+
+func TestDecodeMapField(t *testing.T) {
+	type withMaps struct {
+		Filters map[string]string
+		Tags    map[string][]int
+	}
+	var dst withMaps
+	src := map[string][]string{
+		"Filters.color": {"red"},
+		"Tags.nums":     {"1", "2", "3"},
+	}
+	if err := NewDecoder().Decode(&dst, src); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if dst.Filters["color"] != "red" {
+		t.Errorf("Filters[color] = %q, want %q", dst.Filters["color"], "red")
+	}
+	if want := []int{1, 2, 3}; len(dst.Tags["nums"]) != len(want) {
+		t.Errorf("Tags[nums] = %v, want %v", dst.Tags["nums"], want)
+	}
+}
+
+func TestDecodeMapKeyWithDot(t *testing.T) {
+	// A bracketed map key may legitimately contain a literal ".".
+	type withFilters struct {
+		Filters map[string]string
+	}
+	d := NewDecoder()
+	d.SetPathStyle(PathAny)
+	var dst withFilters
+	src := map[string][]string{"Filters[a.b]": {"x"}}
+	if err := d.Decode(&dst, src); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if dst.Filters["a.b"] != "x" {
+		t.Errorf("Filters[a.b] = %q, want %q", dst.Filters["a.b"], "x")
+	}
+}
+
+func TestDecodeTextUnmarshalerFallback(t *testing.T) {
+	type withID struct {
+		ID hexID
+	}
+	var dst withID
+	src := map[string][]string{"ID": {"2a"}}
+	if err := NewDecoder().Decode(&dst, src); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if dst.ID != 42 {
+		t.Errorf("ID = %d, want 42", dst.ID)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// EncoderFunc converts a value into its string representation.
+type EncoderFunc func(reflect.Value) string
+
+// NewEncoder returns a new Encoder.
+func NewEncoder() *Encoder {
+	return &Encoder{cache: newCache()}
+}
+
+// Encoder encodes values from a struct into map[string][]string.
+type Encoder struct {
+	cache *cache
+}
+
+// SetAliasTag sets the tag used to locate custom field aliases.
+// The default tag is "schema".
+func (e *Encoder) SetAliasTag(tag string) {
+	e.cache.tag = tag
+}
+
+// RegisterEncoder registers a converter function for encoding a custom type.
+//
+// This is only needed for types that implement neither
+// encoding.TextMarshaler nor encoding.BinaryMarshaler, which are used
+// automatically as a fallback when no encoder is registered.
+func (e *Encoder) RegisterEncoder(value interface{}, encoderFunc EncoderFunc) {
+	e.cache.enc[reflect.TypeOf(value)] = encoderFunc
+}
+
+// Encode encodes a struct into map[string][]string.
+//
+// The first parameter must be a struct, or a pointer to one.
+//
+// Keys are written in the same dotted notation expected by Decoder, so
+// a value encoded here can be decoded back with the same tags and
+// conventions. Intended for use with url.Values.
+func (e *Encoder) Encode(src interface{}, dst map[string][]string) error {
+	v := reflect.ValueOf(src)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errors.New("schema: interface must be a struct or a pointer to a struct")
+	}
+	return e.encode(v, "", dst)
+}
+
+// encode writes the fields of v into dst, using path as the prefix for
+// the keys it adds.
+func (e *Encoder) encode(v reflect.Value, path string, dst map[string][]string) error {
+	info, err := e.cache.get(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range info.fields {
+		name := field.alias
+		if path != "" {
+			name = path + "." + name
+		}
+		if err := e.encodeField(v.Field(field.idx), name, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeField writes v, which may be a primitive, a pointer, a struct
+// or a slice of any of those, under name.
+func (e *Encoder) encodeField(v reflect.Value, name string, dst map[string][]string) error {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+		t = t.Elem()
+	}
+	if enc := e.cache.encoderFor(t); enc != nil {
+		dst[name] = append(dst[name], enc(v))
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return e.encode(v, name, dst)
+	case reflect.Slice:
+		elemT := t.Elem()
+		if elemT.Kind() == reflect.Ptr {
+			elemT = elemT.Elem()
+		}
+		indexed := elemT.Kind() == reflect.Struct && e.cache.encoderFor(elemT) == nil
+		for i := 0; i < v.Len(); i++ {
+			name := name
+			if indexed {
+				name = fmt.Sprintf("%s.%d", name, i)
+			}
+			if err := e.encodeField(v.Index(i), name, dst); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keyT := t.Key()
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key()
+			var keyStr string
+			if keyT.Kind() == reflect.String {
+				keyStr = key.String()
+			} else if enc := e.cache.encoderFor(keyT); enc != nil {
+				keyStr = enc(key)
+			} else {
+				return fmt.Errorf("schema: encoder not found for map key type %v", keyT)
+			}
+			if err := e.encodeField(iter.Value(), name+"."+keyStr, dst); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Default encoders for basic types, mirroring the converters table.
+var encoders = map[reflect.Type]EncoderFunc{
+	boolType:    encodeBool,
+	float32Type: encodeFloat32,
+	float64Type: encodeFloat64,
+	intType:     encodeInt,
+	int8Type:    encodeInt,
+	int16Type:   encodeInt,
+	int32Type:   encodeInt,
+	int64Type:   encodeInt,
+	stringType:  encodeString,
+	uintType:    encodeUint,
+	uint8Type:   encodeUint,
+	uint16Type:  encodeUint,
+	uint32Type:  encodeUint,
+	uint64Type:  encodeUint,
+}
+
+func encodeBool(v reflect.Value) string {
+	return strconv.FormatBool(v.Bool())
+}
+
+func encodeFloat32(v reflect.Value) string {
+	return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+}
+
+func encodeFloat64(v reflect.Value) string {
+	return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+}
+
+func encodeInt(v reflect.Value) string {
+	return strconv.FormatInt(v.Int(), 10)
+}
+
+func encodeString(v reflect.Value) string {
+	return v.String()
+}
+
+func encodeUint(v reflect.Value) string {
+	return strconv.FormatUint(v.Uint(), 10)
+}
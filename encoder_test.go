@@ -0,0 +1,82 @@
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import "testing"
+
+func TestEncodeBasic(t *testing.T) {
+	type person struct {
+		Name string `schema:"name"`
+		Age  int    `schema:"age"`
+	}
+	dst := map[string][]string{}
+	src := person{Name: "Ann", Age: 30}
+	if err := NewEncoder().Encode(&src, dst); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if got := dst["name"]; len(got) != 1 || got[0] != "Ann" {
+		t.Errorf("dst[name] = %v, want [Ann]", got)
+	}
+	if got := dst["age"]; len(got) != 1 || got[0] != "30" {
+		t.Errorf("dst[age] = %v, want [30]", got)
+	}
+}
+
+func TestEncodeMapField(t *testing.T) {
+	type withFilters struct {
+		Filters map[string]string
+	}
+	dst := map[string][]string{}
+	src := withFilters{Filters: map[string]string{"color": "red"}}
+	if err := NewEncoder().Encode(&src, dst); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if got := dst["Filters.color"]; len(got) != 1 || got[0] != "red" {
+		t.Errorf("dst[Filters.color] = %v, want [red]", got)
+	}
+}
+
+// weirdMarshaler implements encoding.BinaryMarshaler with a value
+// receiver and encoding.TextMarshaler with a pointer receiver, so the two
+// interfaces are satisfied by different receiver types.
+type weirdMarshaler struct {
+	value string
+}
+
+func (w weirdMarshaler) MarshalBinary() ([]byte, error) {
+	return []byte(w.value), nil
+}
+
+func (w *weirdMarshaler) MarshalText() ([]byte, error) {
+	return []byte("text:" + w.value), nil
+}
+
+func TestEncodeMixedReceiverMarshaler(t *testing.T) {
+	type withWeird struct {
+		W weirdMarshaler
+	}
+	dst := map[string][]string{}
+	src := withWeird{W: weirdMarshaler{value: "hi"}}
+	if err := NewEncoder().Encode(&src, dst); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if got := dst["W"]; len(got) != 1 || got[0] != "text:hi" {
+		t.Errorf("dst[W] = %v, want [text:hi]", got)
+	}
+}
+
+func TestEncodeTextMarshalerFallback(t *testing.T) {
+	type withID struct {
+		ID hexID
+	}
+	dst := map[string][]string{}
+	src := withID{ID: 42}
+	if err := NewEncoder().Encode(&src, dst); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if got := dst["ID"]; len(got) != 1 || got[0] != "2a" {
+		t.Errorf("dst[ID] = %v, want [2a]", got)
+	}
+}
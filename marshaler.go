@@ -0,0 +1,133 @@
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import (
+	"encoding"
+	"reflect"
+)
+
+var (
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	textMarshalerType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+// converter returns the ErrorConverter for t: one registered explicitly
+// via RegisterConverter/RegisterErrorConverter, or, failing that, one
+// built from t's encoding.TextUnmarshaler or encoding.BinaryUnmarshaler
+// implementation. Either way, or if t implements neither, the result is
+// cached in c.conv so the interface assertions happen only once per type.
+func (c *cache) converter(t reflect.Type) ErrorConverter {
+	c.l.Lock()
+	conv, ok := c.conv[t]
+	c.l.Unlock()
+	if ok {
+		return conv
+	}
+	conv = unmarshalerConverter(t)
+	c.l.Lock()
+	c.conv[t] = conv
+	c.l.Unlock()
+	return conv
+}
+
+// unmarshalerConverter returns an ErrorConverter that decodes into a new
+// t using its TextUnmarshaler or BinaryUnmarshaler implementation
+// (checking *t, since the methods almost always have a pointer
+// receiver), or nil if t implements neither.
+func unmarshalerConverter(t reflect.Type) ErrorConverter {
+	ptrT := reflect.PtrTo(t)
+	switch {
+	case ptrT.Implements(textUnmarshalerType):
+		return func(value string) (reflect.Value, error) {
+			v := reflect.New(t)
+			err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+			if err != nil {
+				return invalidValue, err
+			}
+			return v.Elem(), nil
+		}
+	case ptrT.Implements(binaryUnmarshalerType):
+		return func(value string) (reflect.Value, error) {
+			v := reflect.New(t)
+			err := v.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary([]byte(value))
+			if err != nil {
+				return invalidValue, err
+			}
+			return v.Elem(), nil
+		}
+	default:
+		return nil
+	}
+}
+
+// encoderFor returns the EncoderFunc for t: one registered explicitly via
+// RegisterEncoder, or, failing that, one built from t's TextMarshaler or
+// BinaryMarshaler implementation. The result is cached in c.enc so the
+// interface assertions happen only once per type.
+func (c *cache) encoderFor(t reflect.Type) EncoderFunc {
+	c.l.Lock()
+	enc, ok := c.enc[t]
+	c.l.Unlock()
+	if ok {
+		return enc
+	}
+	enc = marshalerEncoder(t)
+	c.l.Lock()
+	c.enc[t] = enc
+	c.l.Unlock()
+	return enc
+}
+
+// marshalerEncoder returns an EncoderFunc that encodes v using its
+// TextMarshaler or BinaryMarshaler implementation, or nil if neither t
+// nor *t implements one. A pointer-receiver method is called against an
+// addressable copy of v if v isn't already addressable. Errors from the
+// Marshal call are swallowed as an empty string; EncoderFunc has no way
+// to report them.
+func marshalerEncoder(t reflect.Type) EncoderFunc {
+	switch {
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return func(v reflect.Value) string {
+			text, err := receiver(v, textMarshalerType).(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return ""
+			}
+			return string(text)
+		}
+	case t.Implements(binaryMarshalerType) || reflect.PtrTo(t).Implements(binaryMarshalerType):
+		return func(v reflect.Value) string {
+			data, err := receiver(v, binaryMarshalerType).(encoding.BinaryMarshaler).MarshalBinary()
+			if err != nil {
+				return ""
+			}
+			return string(data)
+		}
+	default:
+		return nil
+	}
+}
+
+// receiver returns the value to call a possibly pointer-receiver method
+// of iface on: v itself if its type already implements iface, or its
+// address (making an addressable copy first if necessary) otherwise. It
+// must be asked for the specific interface the caller needs, since a
+// type can implement TextMarshaler with a value receiver and
+// BinaryMarshaler with a pointer receiver (or vice versa), in which case
+// only one of the two checks, not the other, holds for v itself.
+func receiver(v reflect.Value, iface reflect.Type) interface{} {
+	t := v.Type()
+	if t.Implements(iface) {
+		return v.Interface()
+	}
+	if !v.CanAddr() {
+		p := reflect.New(t)
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	return v.Addr().Interface()
+}
@@ -0,0 +1,117 @@
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PathStyle selects the notation Decoder accepts for keys in the map
+// passed to Decode.
+type PathStyle int
+
+const (
+	// PathDotted is the default style: "Foo.0.Bar".
+	PathDotted PathStyle = iota
+
+	// PathBracket is the bracketed style commonly produced by browser
+	// form libraries and JSON-to-form bridges: "Foo[0].Bar",
+	// "items[3][name]". A "." is only accepted as a separator right
+	// after a closing "]"; any other bare "." is rejected, so nesting
+	// must be spelled with brackets ("Foo[Bar]", not "Foo.Bar").
+	PathBracket
+
+	// PathAny accepts either PathDotted or PathBracket notation, and
+	// any mix of the two, in the same path.
+	PathAny
+)
+
+// SetPathStyle sets the notation Decoder expects for keys in src. The
+// default is PathDotted.
+func (d *Decoder) SetPathStyle(style PathStyle) {
+	d.pathStyle = style
+}
+
+// splitPath splits path into its segments according to the decoder's
+// configured PathStyle. A segment inside brackets, such as the "a.b" in
+// "Filters[a.b]", is kept intact even if it contains a "." or a "["; it
+// is never split further, so a bracketed map key can contain those
+// characters literally.
+func (d *Decoder) splitPath(path string) ([]string, error) {
+	if d.pathStyle == PathDotted {
+		return strings.Split(path, "."), nil
+	}
+	keys, err := splitBracketPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if d.pathStyle == PathBracket {
+		if err := requireBracketStyle(path); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// requireBracketStyle rejects a path that uses a bare "." to separate
+// segments, such as "Foo.Bar", instead of bracket notation, so that
+// PathBracket actually enforces the style its name promises rather than
+// silently accepting anything PathAny would. A "." immediately after a
+// closing "]", as in "Foo[0].Bar", is a legitimate separator between a
+// bracketed segment and the next one and is allowed; a "." inside a
+// bracket pair, part of a literal key such as "Filters[a.b]", is
+// unaffected since it is never seen outside of brackets.
+func requireBracketStyle(path string) error {
+	inBracket := false
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '[':
+			inBracket = true
+		case ']':
+			inBracket = false
+		case '.':
+			if !inBracket && (i == 0 || path[i-1] != ']') {
+				return fmt.Errorf("schema: path %q is not in bracket notation", path)
+			}
+		}
+	}
+	return nil
+}
+
+// splitBracketPath splits path into its segments, honoring bracket
+// boundaries: the contents of a "[...]" segment are taken as a single
+// key verbatim, even if they contain "." or "[" (the first "]" ends it), so a
+// bracketed map key like "Filters[a.b]" yields the literal key "a.b"
+// instead of being split again on its dot. A "." outside of brackets is
+// a plain segment separator, as is the boundary between one bracketed
+// segment and the next ("items[3][name]").
+func splitBracketPath(path string) ([]string, error) {
+	var parts []string
+	i, n := 0, len(path)
+	for i < n {
+		switch path[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(path[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("schema: unmatched '[' in path %q", path)
+			}
+			parts = append(parts, path[i+1:i+1+end])
+			i += end + 2
+		case ']':
+			return nil, fmt.Errorf("schema: unmatched ']' in path %q", path)
+		default:
+			j := i
+			for j < n && path[j] != '.' && path[j] != '[' {
+				j++
+			}
+			parts = append(parts, path[i:j])
+			i = j
+		}
+	}
+	return parts, nil
+}